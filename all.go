@@ -0,0 +1,49 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import "time"
+
+// AllCmd runs delete, unfav, and unboost back to back, so one invocation
+// handles the full "shrink my footprint" workflow instead of three.
+type AllCmd struct {
+	Globals           `kong:"embed"`
+	ExcludeReplies    bool          `kong:"name='exclude-replies',default=true,negatable,help='exclude replies from filter (default true)'"`
+	ExcludePinned     bool          `kong:"name='exclude-pinned',default=true,negatable,help='exclude toots that are pinned to the profile'"`
+	ExcludeBookmarked bool          `kong:"name='exclude-bookmarked',default=true,negatable,help='exclude toots that are bookmarked'"`
+	ExcludePublic     bool          `kong:"name='exclude-public',default=false,negatable,help='exclude toots with a visibility of public'"`
+	ExcludeBoosts     bool          `kong:"name='exclude-boosts',default=true,negatable,help='exclude boosted statuses from the delete phase, so the unboost phase (which runs after) is the one that handles them (default on)'"`
+	ExcludeDirect     bool          `kong:"name='exclude-dms',default=true,negatable,help='exclude DMs (default on)'"`
+	BurnItAll         bool          `kong:"name='burn-it-all',default=false,help='ignore all exclusions, set no time limit, watch the world burn. slowly'"`
+	Preserve          bool          `kong:"name='preserve',default=true,negatable,help='honor configured content preservation rules (default on)'"`
+	ArchiveDir        string        `kong:"name='archive-dir',help='write a local archive of every status before deleting it'"`
+	UnfavAge          time.Duration `kong:"name='unfav-age',default='${defaultUnfavAge}',help='unfavourite toots favourited this long ago or more'"`
+	UnboostAge        time.Duration `kong:"name='unboost-age',default='${defaultUnboostAge}',help='unboost reblogs this old or older'"`
+}
+
+func (cmd *AllCmd) Run() error {
+	del := Cmd{
+		Globals:           cmd.Globals,
+		ExcludeReplies:    cmd.ExcludeReplies,
+		ExcludePinned:     cmd.ExcludePinned,
+		ExcludeBookmarked: cmd.ExcludeBookmarked,
+		ExcludePublic:     cmd.ExcludePublic,
+		ExcludeBoosts:     cmd.ExcludeBoosts,
+		ExcludeDirect:     cmd.ExcludeDirect,
+		BurnItAll:         cmd.BurnItAll,
+		Preserve:          cmd.Preserve,
+		ArchiveDir:        cmd.ArchiveDir,
+	}
+	if err := del.Run(); err != nil {
+		return err
+	}
+
+	unfav := UnfavCmd{Globals: cmd.Globals, Age: cmd.UnfavAge}
+	if err := unfav.Run(); err != nil {
+		return err
+	}
+
+	unboost := UnboostCmd{Globals: cmd.Globals, Age: cmd.UnboostAge}
+	return unboost.Run()
+}
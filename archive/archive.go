@@ -0,0 +1,201 @@
+// Package archive writes a local, append-only backup of every status
+// tootfyre is about to destroy: a JSON document, a rendered Markdown/HTML
+// sidecar, and a copy of its media attachments.
+package archive
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+const manifestName = "manifest.ndjson"
+
+// manifestEntry is one line of the append-only manifest. Each line stands on
+// its own, so a run that dies mid-archive leaves a manifest that is still
+// valid NDJSON.
+type manifestEntry struct {
+	ID         mastodon.ID `json:"id"`
+	URL        string      `json:"url"`
+	CreatedAt  time.Time   `json:"created_at"`
+	ArchivedAt time.Time   `json:"archived_at"`
+	Dir        string      `json:"dir"`
+}
+
+// Archive writes doomed statuses to disk before tootfyre deletes them.
+type Archive struct {
+	dir      string
+	manifest *os.File
+	client   *http.Client
+}
+
+// New opens (creating if necessary) an archive rooted at dir and prepares its
+// manifest for appending.
+func New(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("archive: creating %s: %w", dir, err)
+	}
+
+	manifest, err := os.OpenFile(filepath.Join(dir, manifestName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("archive: opening manifest: %w", err)
+	}
+
+	return &Archive{
+		dir:      dir,
+		manifest: manifest,
+		client:   http.DefaultClient,
+	}, nil
+}
+
+// Close releases the manifest file handle.
+func (a *Archive) Close() error {
+	return a.manifest.Close()
+}
+
+// Write saves status as JSON plus a rendered Markdown/HTML sidecar, downloads
+// its media attachments, and appends a manifest entry. Every file it writes
+// is fsync'd before Write returns, so callers can treat a successful return
+// as proof the status is safely on disk.
+func (a *Archive) Write(ctx context.Context, status *mastodon.Status) error {
+	dir := filepath.Join(a.dir, string(status.ID))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("archive: creating %s: %w", dir, err)
+	}
+
+	if err := writeFile(filepath.Join(dir, "status.json"), func(f *os.File) error {
+		return json.NewEncoder(f).Encode(status)
+	}); err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(dir, "status.md"), func(f *os.File) error {
+		_, err := f.WriteString(renderMarkdown(status))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(dir, "status.html"), func(f *os.File) error {
+		_, err := f.WriteString(renderHTML(status))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if len(status.MediaAttachments) > 0 {
+		mediaDir := filepath.Join(dir, "media")
+		if err := os.MkdirAll(mediaDir, 0o755); err != nil {
+			return fmt.Errorf("archive: creating %s: %w", mediaDir, err)
+		}
+		for _, att := range status.MediaAttachments {
+			if err := a.downloadMedia(ctx, mediaDir, att); err != nil {
+				return err
+			}
+		}
+	}
+
+	return a.appendManifest(manifestEntry{
+		ID:         status.ID,
+		URL:        status.URL,
+		CreatedAt:  status.CreatedAt,
+		ArchivedAt: time.Now(),
+		Dir:        dir,
+	})
+}
+
+func (a *Archive) downloadMedia(ctx context.Context, dir string, att mastodon.Attachment) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, att.URL, nil)
+	if err != nil {
+		return fmt.Errorf("archive: building request for %s: %w", att.URL, err)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("archive: downloading %s: %w", att.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("archive: downloading %s: status %d", att.URL, resp.StatusCode)
+	}
+
+	name := fmt.Sprintf("%s%s", att.ID, path.Ext(att.URL))
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("archive: creating %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if _, err := f.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("archive: writing %s: %w", name, err)
+	}
+
+	return f.Sync()
+}
+
+func (a *Archive) appendManifest(entry manifestEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("archive: encoding manifest entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := a.manifest.Write(line); err != nil {
+		return fmt.Errorf("archive: writing manifest: %w", err)
+	}
+
+	return a.manifest.Sync()
+}
+
+func writeFile(path string, write func(*os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("archive: creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		return fmt.Errorf("archive: writing %s: %w", path, err)
+	}
+
+	return f.Sync()
+}
+
+func renderMarkdown(status *mastodon.Status) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", status.URL)
+	fmt.Fprintf(&b, "- id: %s\n", status.ID)
+	fmt.Fprintf(&b, "- created: %s\n", status.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- visibility: %s\n", status.Visibility)
+	if status.SpoilerText != "" {
+		fmt.Fprintf(&b, "- content warning: %s\n", status.SpoilerText)
+	}
+	b.WriteString("\n")
+	b.WriteString(status.Content)
+	b.WriteString("\n")
+	return b.String()
+}
+
+func renderHTML(status *mastodon.Status) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!doctype html>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", status.ID)
+	fmt.Fprintf(&b, "<article>\n<p><a href=\"%s\">%s</a></p>\n", status.URL, status.CreatedAt.Format(time.RFC3339))
+	if status.SpoilerText != "" {
+		fmt.Fprintf(&b, "<p class=\"cw\">%s</p>\n", status.SpoilerText)
+	}
+	b.WriteString(status.Content)
+	b.WriteString("\n</article>\n")
+	return b.String()
+}
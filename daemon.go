@@ -0,0 +1,224 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+	"github.com/rs/zerolog/log"
+
+	"git.sr.ht/~sungo/tootfyre/archive"
+)
+
+const (
+	// daemonScanInterval is how often the daemon checks its bucket of known
+	// posts for anything that has aged past retention.
+	daemonScanInterval = time.Minute
+
+	// daemonReconnectMinBackoff and daemonReconnectMaxBackoff bound the
+	// delay between attempts to re-subscribe to the user stream after the
+	// connection drops, so a blip doesn't end the daemon and a dead
+	// instance doesn't get hammered.
+	daemonReconnectMinBackoff = 5 * time.Second
+	daemonReconnectMaxBackoff = 5 * time.Minute
+)
+
+// DaemonCmd keeps tootfyre running continuously. Instead of paginating
+// history on every invocation, it subscribes to the authenticated user's
+// streaming API to learn about new posts as they're published, tracks them
+// in a small on-disk bucket, and deletes anything that crosses the
+// configured retention window. A periodic full backfill sweep reuses the
+// embedded Cmd to catch posts made while the daemon was offline.
+type DaemonCmd struct {
+	Cmd `kong:"embed"`
+
+	StateFile        string        `kong:"name='state-file',default='tootfyre-daemon.json',help='path to the on-disk bucket of known status IDs and timestamps'"`
+	BackfillInterval time.Duration `kong:"name='backfill-interval',default='24h',help='how often to run a full backfill sweep, to catch posts made while the daemon was offline'"`
+}
+
+// bucket is the on-disk record of posts the daemon has learned about via the
+// stream, keyed by status ID and holding each post's creation time.
+type bucket map[mastodon.ID]time.Time
+
+func loadBucket(path string) (bucket, error) {
+	b := make(bucket)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b bucket) save(path string) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (d *DaemonCmd) Run() error {
+	d.setupLogging()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, rl, config, account, err := d.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	b, err := loadBucket(d.StateFile)
+	if err != nil {
+		return err
+	}
+
+	events, err := c.StreamingUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	scanTicker := time.NewTicker(daemonScanInterval)
+	defer scanTicker.Stop()
+
+	backfillTicker := time.NewTicker(d.BackfillInterval)
+	defer backfillTicker.Stop()
+
+	log.Info().Str("account", string(account.Acct)).Dur("backfill_interval", d.BackfillInterval).Msg("daemon started, watching the user stream")
+
+	reconnectBackoff := daemonReconnectMinBackoff
+
+	for {
+		select {
+		case <-ctx.Done():
+			return b.save(d.StateFile)
+
+		case evt, ok := <-events:
+			if !ok {
+				log.Warn().Dur("backoff", reconnectBackoff).Msg("streaming connection closed; reconnecting")
+				select {
+				case <-ctx.Done():
+					return b.save(d.StateFile)
+				case <-time.After(reconnectBackoff):
+				}
+
+				events, err = c.StreamingUser(ctx)
+				if err != nil {
+					log.Error().Err(err).Msg("error reconnecting to the streaming API")
+					if reconnectBackoff < daemonReconnectMaxBackoff {
+						reconnectBackoff *= 2
+					}
+					continue
+				}
+				log.Info().Msg("reconnected to the user stream")
+				reconnectBackoff = daemonReconnectMinBackoff
+				continue
+			}
+			d.handleEvent(b, evt)
+
+		case <-scanTicker.C:
+			d.sweepBucket(ctx, c, rl, config, b)
+
+		case <-backfillTicker.C:
+			log.Info().Msg("running periodic backfill sweep")
+			if err := d.Cmd.Run(); err != nil {
+				log.Error().Err(err).Msg("backfill sweep failed")
+			}
+		}
+	}
+}
+
+// handleEvent updates the bucket in response to a single streaming event.
+func (d *DaemonCmd) handleEvent(b bucket, evt mastodon.Event) {
+	switch e := evt.(type) {
+	case *mastodon.UpdateEvent:
+		b[e.Status.ID] = e.Status.CreatedAt
+		if err := b.save(d.StateFile); err != nil {
+			log.Error().Err(err).Msg("error saving daemon state")
+		}
+	case *mastodon.DeleteEvent:
+		delete(b, e.ID)
+	case *mastodon.ErrorEvent:
+		log.Error().Err(e).Msg("streaming error")
+	}
+}
+
+// sweepBucket deletes anything in the bucket whose age has crossed
+// retention, the same way the one-shot Run does for a single known status.
+func (d *DaemonCmd) sweepBucket(ctx context.Context, c *mastodon.Client, rl *rateLimit, config Config, b bucket) {
+	now := time.Now()
+
+	var archiver *archive.Archive
+	if dir := d.archiveDir(config); dir != "" {
+		a, err := archive.New(dir)
+		if err != nil {
+			log.Error().Err(err).Msg("error opening archive")
+		} else {
+			archiver = a
+			defer archiver.Close()
+		}
+	}
+
+	shortest := config.Retention.shortestWindow()
+
+	for id, createdAt := range b {
+		if now.Sub(createdAt) < shortest {
+			continue
+		}
+
+		status, err := c.GetStatus(ctx, id)
+		if err != nil {
+			log.Error().Err(err).Str("id", string(id)).Msg("error fetching status for retention check")
+			continue
+		}
+		d.rest(rl, restFloorPoll)
+
+		logger := log.With().Interface("id", status.ID).Str("url", status.URL).Logger()
+
+		if ok, reason := d.decide(status, config, now); !ok {
+			logger.Debug().Str("reason", reason).Msg("skipping")
+			if reason != "too young" {
+				delete(b, id)
+			}
+			continue
+		}
+
+		if archiver != nil {
+			if err := archiver.Write(ctx, status); err != nil {
+				logger.Error().Err(err).Msg("error archiving status; skipping deletion")
+				continue
+			}
+		}
+
+		if d.DryRun {
+			logger.Warn().Msg("dry run: would delete status otherwise")
+			continue
+		}
+
+		logger.Info().Msg("deleting status")
+		if err := c.DeleteStatus(ctx, id); err != nil {
+			logger.Error().Err(err).Msg("error when deleting")
+			continue
+		}
+		d.rest(rl, restFloorDelete)
+
+		delete(b, id)
+	}
+
+	if err := b.save(d.StateFile); err != nil {
+		log.Error().Err(err).Msg("error saving daemon state")
+	}
+}
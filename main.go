@@ -7,7 +7,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/alecthomas/kong"
@@ -16,49 +19,82 @@ import (
 	toml "github.com/pelletier/go-toml"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+
+	"git.sr.ht/~sungo/tootfyre/archive"
 )
 
 const (
-	ourName         = "tootfyre"
-	ourVersion      = "0.0.1"
-	ourURL          = "https://git.sr.ht/~sungo/tootfyre.git"
-	timeMax         = -(30 * (24 * (60 * (60 * time.Second))))
-	defaultCount    = "10"
-	paginationLimit = 200
+	ourName           = "tootfyre"
+	ourVersion        = "0.0.1"
+	ourURL            = "https://git.sr.ht/~sungo/tootfyre.git"
+	defaultCount      = "10"
+	defaultUnfavAge   = "720h"
+	defaultUnboostAge = "720h"
+	paginationLimit   = 200
+	restFloorPoll     = 5 * time.Second
+	restFloorDelete   = 15 * time.Second
 )
 
 type (
+	// Globals holds the flags and behavior shared by every subcommand:
+	// where the config lives, how hard to lean on the instance, and how
+	// much to say about it.
+	Globals struct {
+		Config string `kong:"required,name='config',help='path to config file',type='existingfile'"`
+		Slow   bool   `kong:"name='slow',default=true,negatable,help='enforce a minimum rest between API calls on top of the rate-limit governor, to be nice to your instance and the fediverse in general (default on)'"`
+		Count  int    `kong:"name='count',default='${defaultCount}',help='the number of toots to act on in this run'"`
+		DryRun bool   `kong:"name='dry-run',short='n',default=false,help='do not do the thing just log about the thing'"`
+		Quiet  bool   `kong:"name='quiet',default=false,help='only log about errors and the stuff we acted on'"`
+	}
 	Cmd struct {
-		Config            string `kong:"required,name='config',help='path to config file',type='existingfile'"`
-		Slow              bool   `kong:"name='slow',default=true,negatable,help='delete stuff at a slow pace to be nice to your instance and the fediverse in general (default on)'"`
+		Globals           `kong:"embed"`
 		ExcludeReplies    bool   `kong:"name='exclude-replies',default=true,negatable,help='exclude replies from filter (default true)'"`
 		ExcludePinned     bool   `kong:"name='exclude-pinned',default=true,negatable,help='exclude toots that are pinned to the profile'"`
 		ExcludeBookmarked bool   `kong:"name='exclude-bookmarked',default=true,negatable,help='exclude toots that are bookmarked'"`
 		ExcludePublic     bool   `kong:"name='exclude-public',default=false,negatable,help='exclude toots with a visibility of public'"`
 		ExcludeBoosts     bool   `kong:"name='exclude-boosts',default=false,negatable,help='exclude boosted'"`
 		ExcludeDirect     bool   `kong:"name='exclude-dms',default=true,negatable,help='exclude DMs (default on)'"`
-		Count             int    `kong:"name='count',default='${defaultCount}',help='the number of toots to act on in this run'"`
-		DryRun            bool   `kong:"name='dry-run',short='n',default=false,help='do not do the thing just log about the thing'"`
-		Quiet             bool   `kong:"name='quiet',default=false,help='only log about errors and the stuff we deleted'"`
 		BurnItAll         bool   `kong:"name='burn-it-all',default=false,help='ignore all exclusions, set no time limit, watch the world burn. slowly'"`
+		Preserve          bool   `kong:"name='preserve',default=true,negatable,help='honor configured content preservation rules (default on)'"`
+		ArchiveDir        string `kong:"name='archive-dir',help='write a local archive of every status before deleting it'"`
+		SnapshotFile      string `kong:"name='snapshot-file',help='path to the resumable run-state snapshot (default: next to --config)'"`
 	}
 	Config struct {
 		Server       string
 		ClientID     string
 		ClientSecret string
 		AccessToken  string
+		Preserve     []PreservationRule `toml:"preserve"`
+		Retention    RetentionConfig    `toml:"retention"`
+		Archive      struct {
+			Dir string `toml:"dir"`
+		} `toml:"archive"`
 	}
 )
 
+// CLI is the root Kong command. Delete is the original one-shot sweep and
+// remains the default so existing invocations (`tootfyre --config=...`)
+// keep working without naming a subcommand.
+type CLI struct {
+	Delete  Cmd        `kong:"cmd,default='withargs',help='sweep the timeline once and delete anything past retention'"`
+	Unfav   UnfavCmd   `kong:"cmd,help='unfavourite toots favourited longer ago than --age'"`
+	Unboost UnboostCmd `kong:"cmd,help='unboost reblogs older than --age, without deleting them'"`
+	All     AllCmd     `kong:"cmd,help='run delete, unfav, and unboost in sequence'"`
+	Daemon  DaemonCmd  `kong:"cmd,help='stay running, watching the user stream for new posts and deleting on schedule'"`
+}
+
 func main() {
-	ctx := kong.Parse(&Cmd{}, kong.Vars{
-		"defaultCount": defaultCount,
+	var cli CLI
+	ctx := kong.Parse(&cli, kong.Vars{
+		"defaultCount":      defaultCount,
+		"defaultUnfavAge":   defaultUnfavAge,
+		"defaultUnboostAge": defaultUnboostAge,
 	})
 	err := ctx.Run()
 	ctx.FatalIfErrorf(err)
 }
 
-func (cmd *Cmd) LoadConfig(path string) (Config, error) {
+func (g *Globals) LoadConfig(path string) (Config, error) {
 	config := Config{}
 
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -84,11 +120,17 @@ func (cmd *Cmd) LoadConfig(path string) (Config, error) {
 		return config, errors.New("'accesstoken' is required in config. get this from headers in an existing UI client")
 	}
 
+	for i := range config.Preserve {
+		if err := config.Preserve[i].Compile(); err != nil {
+			return config, err
+		}
+	}
+
 	return config, nil
 }
 
-func (cmd *Cmd) WriteConfig(config Config) error {
-	file, err := os.Create(cmd.Config)
+func (g *Globals) WriteConfig(config Config) error {
+	file, err := os.Create(g.Config)
 	if err != nil {
 		return err
 	}
@@ -101,32 +143,52 @@ func (cmd *Cmd) WriteConfig(config Config) error {
 	return nil
 }
 
-func (cmd *Cmd) Rest(secs int) {
-	if cmd.Slow {
-		log.Debug().Int("seconds", secs).Msg("slow mode engaged. resting")
-		time.Sleep(time.Duration(secs) * time.Second)
+// archiveDir resolves the effective archive directory, preferring the
+// --archive-dir flag over the config file's [archive] table. An empty result
+// means archiving is disabled.
+func (cmd *Cmd) archiveDir(config Config) string {
+	if cmd.ArchiveDir != "" {
+		return cmd.ArchiveDir
 	}
+	return config.Archive.Dir
 }
 
-func (cmd *Cmd) Run() error {
+// rest waits out whatever the rate-limit governor says is left of our
+// budget before the next call. With --slow, that wait is never shorter than
+// floor, to stay nice to the instance even when plenty of budget remains.
+func (g *Globals) rest(rl *rateLimit, floor time.Duration) {
+	d := rl.sleepDuration()
+	if g.Slow && d < floor {
+		d = floor
+	}
+	if d <= 0 {
+		return
+	}
+
+	log.Debug().Dur("duration", d).Msg("rate limit governor: resting")
+	time.Sleep(d)
+}
+
+// setupLogging configures console output and verbosity. Every subcommand
+// calls this first, before touching the network.
+func (g *Globals) setupLogging() {
 	if isatty.IsTerminal(os.Stdout.Fd()) {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	}
-	if cmd.Quiet {
+	if g.Quiet {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	} else {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
+}
 
-	var (
-		endTime     = time.Now().Add(timeMax)
-		ctx, cancel = context.WithCancel(context.Background())
-	)
-	defer cancel()
-
-	config, err := cmd.LoadConfig(cmd.Config)
+// connect loads the config, registers the app if necessary, and returns a
+// ready-to-use client along with the authenticated account. Every subcommand
+// that talks to the instance starts here.
+func (g *Globals) connect(ctx context.Context) (*mastodon.Client, *rateLimit, Config, *mastodon.Account, error) {
+	config, err := g.LoadConfig(g.Config)
 	if err != nil {
-		return err
+		return nil, nil, config, nil, err
 	}
 
 	if (config.ClientID == "") || (config.ClientSecret == "") {
@@ -139,12 +201,12 @@ func (cmd *Cmd) Run() error {
 			Website:    ourURL,
 		})
 		if err != nil {
-			return err
+			return nil, nil, config, nil, err
 		}
 		config.ClientID = app.ClientID
 		config.ClientSecret = app.ClientSecret
 
-		cmd.WriteConfig(config)
+		g.WriteConfig(config)
 	}
 
 	c := mastodon.NewClient(&mastodon.Config{
@@ -155,22 +217,113 @@ func (cmd *Cmd) Run() error {
 	})
 	c.UserAgent = fmt.Sprintf("%s/%s", ourName, ourVersion)
 
+	rl := &rateLimit{}
+	c.Client.Transport = &rateLimitTransport{base: http.DefaultTransport, limit: rl}
+
 	log.Debug().Msg("getting account info")
 	account, err := c.GetAccountCurrentUser(ctx)
+	if err != nil {
+		return nil, nil, config, nil, err
+	}
+
+	return c, rl, config, account, nil
+}
+
+// decide reports whether status should be deleted under the command's
+// current flags and config, along with a short reason to log when it isn't.
+// now is accepted as a parameter, rather than read from time.Now(), so a
+// single sweep judges every status against the same instant.
+func (cmd *Cmd) decide(status *mastodon.Status, config Config, now time.Time) (shouldDelete bool, reason string) {
+	if !cmd.BurnItAll {
+		cutoff := now.Add(-config.Retention.maxAge(status))
+		if !status.CreatedAt.Before(cutoff) {
+			return false, "too young"
+		}
+		switch {
+		case cmd.ExcludePinned && status.Pinned == true:
+			return false, "pinned"
+		case cmd.ExcludePublic && status.Visibility == mastodon.VisibilityPublic:
+			return false, "public"
+		case cmd.ExcludeBookmarked && status.Bookmarked == true:
+			return false, "bookmarked"
+		case cmd.ExcludeBoosts && status.Reblog != nil:
+			return false, "boost"
+		case cmd.ExcludeReplies && status.InReplyToID != nil:
+			return false, "reply"
+		case cmd.ExcludeDirect && status.Visibility == mastodon.VisibilityDirectMessage:
+			return false, "dm"
+		}
+	}
+
+	if cmd.Preserve {
+		if ok, rule := preserved(status, config.Preserve); ok {
+			return false, fmt.Sprintf("preservation rule %q", rule)
+		}
+	}
+
+	return true, ""
+}
+
+// statusLogger builds the standard set of fields we log a status with,
+// whether we're considering it, queuing it, or deleting it.
+func statusLogger(status *mastodon.Status) zerolog.Logger {
+	return log.With().
+		Interface("id", status.ID).
+		Str("url", status.URL).
+		Time("created", status.CreatedAt).
+		Str("content", status.Content).
+		Bool("is_reply", status.InReplyToID != nil).
+		Bool("is_boost", status.Reblog != nil).
+		Str("visibility", status.Visibility).
+		Bool("pinned", status.Pinned == true).
+		Bool("bookmarked", status.Bookmarked == true).
+		Bool("favstarred", status.Favourited == true).
+		Logger()
+}
+
+func (cmd *Cmd) Run() error {
+	cmd.setupLogging()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	now := time.Now()
+
+	snapPath := cmd.snapshotPath()
+	snap, err := loadSnapshot(snapPath)
+	if err != nil {
+		return err
+	}
+	defer snap.save(snapPath)
+
+	c, rl, config, account, err := cmd.connect(ctx)
 	if err != nil {
 		return err
 	}
 
+	toDelete := make([]*mastodon.Status, 0)
+
+	for id, state := range snap.Results {
+		if state == stateDeleted {
+			continue
+		}
+		status, err := c.GetStatus(ctx, id)
+		if err != nil {
+			log.Warn().Err(err).Str("id", string(id)).Msg("queued status from a prior run is gone; dropping it")
+			delete(snap.Results, id)
+			continue
+		}
+		log.Debug().Str("id", string(id)).Msg("retrying status queued by a prior run")
+		toDelete = append(toDelete, status)
+	}
+
 	var (
-		pg       mastodon.Pagination
-		toDelete = make([]*mastodon.Status, 0)
+		pg = mastodon.Pagination{MaxID: snap.MaxID, Limit: int64(paginationLimit)}
 	)
-
-	pg.Limit = int64(paginationLimit)
-	log.Debug().Int("max_toots", cmd.Count).Time("before", endTime).Msg("starting run")
+	log.Debug().Int("max_toots", cmd.Count).Time("now", now).Str("resume_cursor", string(snap.MaxID)).Msg("starting run")
 
 LOOP:
-	for {
+	for ctx.Err() == nil {
 		log.Debug().Msgf("Polling for toots before ID %s, max of %d", pg.MaxID, pg.Limit)
 		statuses, err := c.GetAccountStatuses(ctx, account.ID, &pg)
 		if err != nil {
@@ -181,57 +334,31 @@ LOOP:
 
 		for id := range statuses {
 			status := statuses[id]
-			logger := log.With().
-				Interface("id", status.ID).
-				Str("url", status.URL).
-				Time("created", status.CreatedAt).
-				Str("content", status.Content).
-				Bool("is_reply", status.InReplyToID != nil).
-				Bool("is_boost", status.Reblog != nil).
-				Str("visibility", status.Visibility).
-				Bool("pinned", status.Pinned == true).
-				Bool("bookmarked", status.Bookmarked == true).
-				Bool("favstarred", status.Favourited == true).
-				Logger()
-
-			if !cmd.BurnItAll {
-				if !status.CreatedAt.Before(endTime) {
-					logger.Debug().Msg("skipping for being too young")
-					continue
-				}
-				switch {
-				case cmd.ExcludePinned && status.Pinned == true:
-					logger.Debug().Msg("skipping due to pinned")
-					continue
-				case cmd.ExcludePublic && status.Visibility == mastodon.VisibilityPublic:
-					logger.Debug().Msg("skipping due to being public")
-					continue
-				case cmd.ExcludeBookmarked && status.Bookmarked == true:
-					logger.Debug().Msg("skipping due to being bookmarked")
-					continue
-				case cmd.ExcludeBoosts && status.Reblog != nil:
-					logger.Debug().Msg("skipping due to being a boost")
-					continue
-				case cmd.ExcludeReplies && status.InReplyToID != nil:
-					logger.Debug().Msg("skipping due to being a reply")
-					continue
-				case cmd.ExcludeDirect && status.Visibility == mastodon.VisibilityDirectMessage:
-					logger.Debug().Msg("skipping due to being a DM")
-					continue
-				}
+			logger := statusLogger(status)
+
+			if _, seen := snap.Results[status.ID]; seen {
+				logger.Debug().Msg("already queued by the resume pass; skipping")
+				continue
 			}
 
+			if ok, reason := cmd.decide(status, config, now); !ok {
+				logger.Debug().Str("reason", reason).Msg("skipping")
+				continue
+			}
+
+			snap.Results[status.ID] = stateQueued
 			toDelete = append(toDelete, status)
 			if len(toDelete) >= cmd.Count {
 				break LOOP
 			}
 		}
 
-		if pg.MaxID == "" {
-			break LOOP
+		snap.MaxID = pg.MaxID
+		if err := snap.save(snapPath); err != nil {
+			return err
 		}
 
-		if pg.MinID == "" {
+		if pg.MaxID == "" || pg.MinID == "" {
 			break LOOP
 		}
 
@@ -239,35 +366,48 @@ LOOP:
 		pg.MinID = ""
 		pg.Limit = paginationLimit
 
-		cmd.Rest(5)
+		cmd.rest(rl, restFloorPoll)
 	}
 	log.Info().Msgf("Found %d statuses to delete", len(toDelete))
 
-	for idx := len(toDelete) - 1; idx >= 0; idx-- {
+	var archiver *archive.Archive
+	if dir := cmd.archiveDir(config); dir != "" {
+		archiver, err = archive.New(dir)
+		if err != nil {
+			return err
+		}
+		defer archiver.Close()
+	}
+
+	for idx := len(toDelete) - 1; idx >= 0 && ctx.Err() == nil; idx-- {
 		status := toDelete[idx]
-		logger := log.With().
-			Interface("id", status.ID).
-			Str("url", status.URL).
-			Time("created", status.CreatedAt).
-			Str("content", status.Content).
-			Bool("is_reply", status.InReplyToID != nil).
-			Bool("is_boost", status.Reblog != nil).
-			Str("visibility", status.Visibility).
-			Bool("pinned", status.Pinned == true).
-			Bool("bookmarked", status.Bookmarked == true).
-			Bool("favstarred", status.Favourited == true).
-			Logger()
+		logger := statusLogger(status)
+
+		if archiver != nil {
+			if err := archiver.Write(ctx, status); err != nil {
+				logger.Error().Err(err).Msg("error archiving status; skipping deletion")
+				continue
+			}
+		}
 
 		if cmd.DryRun {
 			logger.Warn().Msg("dry run: would delete status otherwise")
+			continue
+		}
+
+		logger.Info().Msg("deleting status")
+		if err := c.DeleteStatus(ctx, status.ID); err != nil {
+			logger.Error().Err(err).Msg("error when deleting")
+			snap.Results[status.ID] = stateError
 		} else {
-			logger.Info().Msg("deleting status")
-			if err := c.DeleteStatus(ctx, status.ID); err != nil {
-				logger.Error().Err(err).Msg("error when deleting")
-			}
+			snap.Results[status.ID] = stateDeleted
+		}
 
-			cmd.Rest(15)
+		if err := snap.save(snapPath); err != nil {
+			return err
 		}
+
+		cmd.rest(rl, restFloorDelete)
 	}
 
 	return nil
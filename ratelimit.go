@@ -0,0 +1,81 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimit tracks the instance's most recently reported rate-limit budget,
+// read off the `X-RateLimit-Remaining` / `X-RateLimit-Reset` headers that
+// every Mastodon API response carries. go-mastodon doesn't surface response
+// headers itself, so a rateLimitTransport captures them on our behalf.
+type rateLimit struct {
+	mu        sync.Mutex
+	seen      bool
+	remaining int64
+	reset     time.Time
+}
+
+func (r *rateLimit) update(resp *http.Response) {
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	reset := resp.Header.Get("X-RateLimit-Reset")
+	if remaining == "" || reset == "" {
+		return
+	}
+
+	n, err := strconv.ParseInt(remaining, 10, 64)
+	if err != nil {
+		return
+	}
+	t, err := time.Parse(time.RFC3339, reset)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen = true
+	r.remaining = n
+	r.reset = t
+}
+
+// sleepDuration spreads whatever budget is left evenly across the time
+// remaining until reset. It returns 0 until the first response has told us
+// anything, or once the window has already turned over.
+func (r *rateLimit) sleepDuration() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.seen || r.remaining <= 0 {
+		return 0
+	}
+
+	remain := time.Until(r.reset)
+	if remain <= 0 {
+		return 0
+	}
+
+	return remain / time.Duration(r.remaining)
+}
+
+// rateLimitTransport wraps an http.RoundTripper to feed every response
+// through a rateLimit, since go-mastodon's Client embeds http.Client and
+// gives callers no other hook into the responses it receives.
+type rateLimitTransport struct {
+	base  http.RoundTripper
+	limit *rateLimit
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	t.limit.update(resp)
+	return resp, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitSleepDuration(t *testing.T) {
+	t.Run("unseen budget sleeps zero", func(t *testing.T) {
+		var rl rateLimit
+		if got := rl.sleepDuration(); got != 0 {
+			t.Errorf("sleepDuration() = %v, want 0", got)
+		}
+	})
+
+	t.Run("exhausted budget sleeps zero", func(t *testing.T) {
+		rl := rateLimit{seen: true, remaining: 0, reset: time.Now().Add(time.Minute)}
+		if got := rl.sleepDuration(); got != 0 {
+			t.Errorf("sleepDuration() = %v, want 0", got)
+		}
+	})
+
+	t.Run("elapsed window sleeps zero", func(t *testing.T) {
+		rl := rateLimit{seen: true, remaining: 10, reset: time.Now().Add(-time.Minute)}
+		if got := rl.sleepDuration(); got != 0 {
+			t.Errorf("sleepDuration() = %v, want 0", got)
+		}
+	})
+
+	t.Run("spreads remaining time across remaining budget", func(t *testing.T) {
+		rl := rateLimit{seen: true, remaining: 10, reset: time.Now().Add(100 * time.Second)}
+		got := rl.sleepDuration()
+		if got <= 9*time.Second || got > 10*time.Second {
+			t.Errorf("sleepDuration() = %v, want ~10s", got)
+		}
+	})
+}
+
+func TestRateLimitUpdate(t *testing.T) {
+	reset := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	var rl rateLimit
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("X-RateLimit-Remaining", "42")
+	resp.Header.Set("X-RateLimit-Reset", reset)
+
+	rl.update(resp)
+
+	if !rl.seen || rl.remaining != 42 {
+		t.Errorf("update() left seen=%v remaining=%d, want seen=true remaining=42", rl.seen, rl.remaining)
+	}
+}
+
+func TestRateLimitUpdateIgnoresMissingHeaders(t *testing.T) {
+	var rl rateLimit
+	resp := &http.Response{Header: http.Header{}}
+
+	rl.update(resp)
+
+	if rl.seen {
+		t.Errorf("update() set seen=true with no rate-limit headers present")
+	}
+}
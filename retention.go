@@ -0,0 +1,75 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// defaultRetentionWindow is how long a status is kept when nothing in
+// [retention] applies to it.
+const defaultRetentionWindow = 30 * 24 * time.Hour
+
+// RetentionConfig lets users set independent max ages per visibility and per
+// kind of status, replacing what used to be a single hardcoded window.
+type RetentionConfig struct {
+	Public    time.Duration `toml:"public"`
+	Unlisted  time.Duration `toml:"unlisted"`
+	Private   time.Duration `toml:"private"`
+	Direct    time.Duration `toml:"direct"`
+	Boosts    time.Duration `toml:"boosts"`
+	Replies   time.Duration `toml:"replies"`
+	Originals time.Duration `toml:"originals"`
+}
+
+// maxAge returns how long status is allowed to live: whichever configured
+// window is most specific to it, kind taking priority over visibility since
+// a boost is a boost no matter who can see it. Falls back to
+// defaultRetentionWindow when nothing configured applies.
+func (r RetentionConfig) maxAge(status *mastodon.Status) time.Duration {
+	var kind time.Duration
+	switch {
+	case status.Reblog != nil:
+		kind = r.Boosts
+	case status.InReplyToID != nil:
+		kind = r.Replies
+	default:
+		kind = r.Originals
+	}
+	if kind > 0 {
+		return kind
+	}
+
+	var vis time.Duration
+	switch status.Visibility {
+	case mastodon.VisibilityPublic:
+		vis = r.Public
+	case mastodon.VisibilityUnlisted:
+		vis = r.Unlisted
+	case mastodon.VisibilityFollowersOnly:
+		vis = r.Private
+	case mastodon.VisibilityDirectMessage:
+		vis = r.Direct
+	}
+	if vis > 0 {
+		return vis
+	}
+
+	return defaultRetentionWindow
+}
+
+// shortestWindow returns the shortest of all the configured windows, falling
+// back to defaultRetentionWindow when nothing is configured. It lets callers
+// cheaply rule out a status as "definitely too young" without fetching it.
+func (r RetentionConfig) shortestWindow() time.Duration {
+	shortest := defaultRetentionWindow
+	for _, d := range []time.Duration{r.Public, r.Unlisted, r.Private, r.Direct, r.Boosts, r.Replies, r.Originals} {
+		if d > 0 && d < shortest {
+			shortest = d
+		}
+	}
+	return shortest
+}
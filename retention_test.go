@@ -0,0 +1,73 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestRetentionConfigMaxAge(t *testing.T) {
+	hour := time.Hour
+
+	cases := []struct {
+		name   string
+		config RetentionConfig
+		status mastodon.Status
+		want   time.Duration
+	}{
+		{
+			name:   "nothing configured falls back to default",
+			config: RetentionConfig{},
+			status: mastodon.Status{Visibility: mastodon.VisibilityPublic},
+			want:   defaultRetentionWindow,
+		},
+		{
+			name:   "visibility window applies to an original",
+			config: RetentionConfig{Public: hour},
+			status: mastodon.Status{Visibility: mastodon.VisibilityPublic},
+			want:   hour,
+		},
+		{
+			name:   "kind window wins over visibility window",
+			config: RetentionConfig{Public: hour, Boosts: 2 * hour},
+			status: mastodon.Status{
+				Visibility: mastodon.VisibilityPublic,
+				Reblog:     &mastodon.Status{},
+			},
+			want: 2 * hour,
+		},
+		{
+			name:   "a reply uses the replies window",
+			config: RetentionConfig{Replies: 3 * hour},
+			status: mastodon.Status{
+				Visibility:  mastodon.VisibilityUnlisted,
+				InReplyToID: "12345",
+			},
+			want: 3 * hour,
+		},
+		{
+			name:   "an original falls back to visibility when originals unset",
+			config: RetentionConfig{Private: 4 * hour},
+			status: mastodon.Status{Visibility: mastodon.VisibilityFollowersOnly},
+			want:   4 * hour,
+		},
+		{
+			name:   "direct messages can be configured longer than the default",
+			config: RetentionConfig{Direct: 365 * 24 * hour},
+			status: mastodon.Status{Visibility: mastodon.VisibilityDirectMessage},
+			want:   365 * 24 * hour,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.config.maxAge(&tc.status); got != tc.want {
+				t.Errorf("maxAge() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// PreservationRule describes a condition under which a status should survive
+// the delete pass even though it would otherwise be eligible for removal.
+// Rules are composable: if any configured rule matches a status, that status
+// is kept.
+type PreservationRule struct {
+	Name           string   `toml:"name"`
+	ContentPattern string   `toml:"content_pattern"`
+	SpoilerPattern string   `toml:"spoiler_pattern"`
+	Hashtags       []string `toml:"hashtags"`
+	Mentions       []string `toml:"mentions"`
+	MinFavourites  int64    `toml:"min_favourites"`
+	MinReblogs     int64    `toml:"min_reblogs"`
+
+	contentRe *regexp.Regexp
+	spoilerRe *regexp.Regexp
+}
+
+// Compile parses the rule's regex patterns once so Matches can run cheaply
+// against every status under consideration.
+func (r *PreservationRule) Compile() error {
+	if r.ContentPattern != "" {
+		re, err := regexp.Compile(r.ContentPattern)
+		if err != nil {
+			return fmt.Errorf("rule %q: content_pattern: %w", r.displayName(), err)
+		}
+		r.contentRe = re
+	}
+	if r.SpoilerPattern != "" {
+		re, err := regexp.Compile(r.SpoilerPattern)
+		if err != nil {
+			return fmt.Errorf("rule %q: spoiler_pattern: %w", r.displayName(), err)
+		}
+		r.spoilerRe = re
+	}
+	return nil
+}
+
+// Matches reports whether status satisfies this rule.
+func (r *PreservationRule) Matches(status *mastodon.Status) bool {
+	switch {
+	case r.contentRe != nil && r.contentRe.MatchString(status.Content):
+		return true
+	case r.spoilerRe != nil && r.spoilerRe.MatchString(status.SpoilerText):
+		return true
+	case len(r.Hashtags) > 0 && tagsContainAny(status.Tags, r.Hashtags):
+		return true
+	case len(r.Mentions) > 0 && mentionsContainAny(status.Mentions, r.Mentions):
+		return true
+	case r.MinFavourites > 0 && status.FavouritesCount >= r.MinFavourites:
+		return true
+	case r.MinReblogs > 0 && status.ReblogsCount >= r.MinReblogs:
+		return true
+	}
+	return false
+}
+
+func (r *PreservationRule) displayName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return "(unnamed rule)"
+}
+
+func tagsContainAny(tags []mastodon.Tag, whitelist []string) bool {
+	for _, tag := range tags {
+		for _, name := range whitelist {
+			if strings.EqualFold(tag.Name, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func mentionsContainAny(mentions []mastodon.Mention, whitelist []string) bool {
+	for _, mention := range mentions {
+		for _, acct := range whitelist {
+			if strings.EqualFold(mention.Acct, acct) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// preserved evaluates all configured rules against status, returning true and
+// the name of the first matching rule if any rule says to keep it.
+func preserved(status *mastodon.Status, rules []PreservationRule) (bool, string) {
+	for i := range rules {
+		if rules[i].Matches(status) {
+			return true, rules[i].displayName()
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,128 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestPreservationRuleMatches(t *testing.T) {
+	cases := []struct {
+		name   string
+		rule   PreservationRule
+		status mastodon.Status
+		want   bool
+	}{
+		{
+			name: "content pattern match",
+			rule: PreservationRule{ContentPattern: `(?i)keep me`},
+			status: mastodon.Status{
+				Content: "please Keep Me around",
+			},
+			want: true,
+		},
+		{
+			name: "content pattern miss",
+			rule: PreservationRule{ContentPattern: `(?i)keep me`},
+			status: mastodon.Status{
+				Content: "nothing special here",
+			},
+			want: false,
+		},
+		{
+			name: "spoiler pattern match",
+			rule: PreservationRule{SpoilerPattern: `^pinned:`},
+			status: mastodon.Status{
+				SpoilerText: "pinned: announcement",
+			},
+			want: true,
+		},
+		{
+			name: "hashtag match is case insensitive",
+			rule: PreservationRule{Hashtags: []string{"Archive"}},
+			status: mastodon.Status{
+				Tags: []mastodon.Tag{{Name: "archive"}},
+			},
+			want: true,
+		},
+		{
+			name: "mention match is case insensitive",
+			rule: PreservationRule{Mentions: []string{"Friend@example.com"}},
+			status: mastodon.Status{
+				Mentions: []mastodon.Mention{{Acct: "friend@example.com"}},
+			},
+			want: true,
+		},
+		{
+			name: "min favourites threshold",
+			rule: PreservationRule{MinFavourites: 10},
+			status: mastodon.Status{
+				FavouritesCount: 10,
+			},
+			want: true,
+		},
+		{
+			name: "below min favourites threshold",
+			rule: PreservationRule{MinFavourites: 10},
+			status: mastodon.Status{
+				FavouritesCount: 9,
+			},
+			want: false,
+		},
+		{
+			name: "min reblogs threshold",
+			rule: PreservationRule{MinReblogs: 5},
+			status: mastodon.Status{
+				ReblogsCount: 5,
+			},
+			want: true,
+		},
+		{
+			name:   "empty rule never matches",
+			rule:   PreservationRule{},
+			status: mastodon.Status{Content: "anything"},
+			want:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule := tc.rule
+			if err := rule.Compile(); err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if got := rule.Matches(&tc.status); got != tc.want {
+				t.Errorf("Matches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPreserved(t *testing.T) {
+	rules := []PreservationRule{
+		{Name: "pinned-tag", Hashtags: []string{"pinned"}},
+		{Name: "popular", MinFavourites: 100},
+	}
+	for i := range rules {
+		if err := rules[i].Compile(); err != nil {
+			t.Fatalf("Compile: %v", err)
+		}
+	}
+
+	status := &mastodon.Status{
+		Tags: []mastodon.Tag{{Name: "pinned"}},
+	}
+	ok, name := preserved(status, rules)
+	if !ok || name != "pinned-tag" {
+		t.Errorf("preserved() = (%v, %q), want (true, %q)", ok, name, "pinned-tag")
+	}
+
+	status = &mastodon.Status{}
+	ok, name = preserved(status, rules)
+	if ok || name != "" {
+		t.Errorf("preserved() = (%v, %q), want (false, \"\")", ok, name)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// resultState is the outcome recorded for a status once it's been queued for
+// deletion. Anything other than stateDeleted is retried on the next run.
+type resultState string
+
+const (
+	stateQueued  resultState = "queued"
+	stateDeleted resultState = "deleted"
+	stateError   resultState = "error"
+)
+
+// Snapshot is the resumable state for a delete sweep: the pagination cursor
+// we'd reached, and the recorded outcome for every status already queued.
+// It's loaded at the start of a run and saved as the run progresses, so a
+// killed process picks back up instead of losing everything.
+type Snapshot struct {
+	MaxID   mastodon.ID                 `json:"max_id"`
+	Results map[mastodon.ID]resultState `json:"results"`
+}
+
+func loadSnapshot(path string) (*Snapshot, error) {
+	snap := &Snapshot{Results: make(map[mastodon.ID]resultState)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return snap, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, err
+	}
+	if snap.Results == nil {
+		snap.Results = make(map[mastodon.ID]resultState)
+	}
+
+	return snap, nil
+}
+
+func (s *Snapshot) save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// snapshotPath resolves the effective snapshot file path, defaulting to a
+// file next to the config.
+func (cmd *Cmd) snapshotPath() string {
+	if cmd.SnapshotFile != "" {
+		return cmd.SnapshotFile
+	}
+	return cmd.Config + ".snapshot.json"
+}
@@ -0,0 +1,53 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestLoadSnapshotMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+
+	snap, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatalf("loadSnapshot: %v", err)
+	}
+	if snap.MaxID != "" {
+		t.Errorf("MaxID = %q, want empty", snap.MaxID)
+	}
+	if snap.Results == nil {
+		t.Error("Results = nil, want an initialized map")
+	}
+}
+
+func TestSnapshotSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	want := &Snapshot{
+		MaxID: mastodon.ID("100"),
+		Results: map[mastodon.ID]resultState{
+			"1": stateDeleted,
+			"2": stateQueued,
+			"3": stateError,
+		},
+	}
+
+	if err := want.save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	got, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatalf("loadSnapshot: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadSnapshot() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"context"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+	"github.com/rs/zerolog/log"
+)
+
+// UnboostCmd walks the authenticated user's own statuses and unreblogs
+// anything boosted longer ago than Age, rather than deleting it via
+// DeleteStatus.
+type UnboostCmd struct {
+	Globals `kong:"embed"`
+	Age     time.Duration `kong:"name='age',default='${defaultUnboostAge}',help='unboost reblogs this old or older'"`
+}
+
+func (cmd *UnboostCmd) Run() error {
+	cmd.setupLogging()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, rl, _, account, err := cmd.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-cmd.Age)
+
+	var (
+		pg        mastodon.Pagination
+		toUnboost = make([]*mastodon.Status, 0)
+	)
+
+	pg.Limit = int64(paginationLimit)
+	log.Debug().Int("max_toots", cmd.Count).Time("cutoff", cutoff).Msg("starting unboost run")
+
+LOOP:
+	for {
+		statuses, err := c.GetAccountStatuses(ctx, account.ID, &pg)
+		if err != nil {
+			return err
+		}
+
+		log.Debug().Int("count", len(statuses)).Msg("found statuses to consider")
+
+		for _, status := range statuses {
+			if status.Reblog == nil {
+				continue
+			}
+			if !status.CreatedAt.Before(cutoff) {
+				continue
+			}
+
+			toUnboost = append(toUnboost, status)
+			if len(toUnboost) >= cmd.Count {
+				break LOOP
+			}
+		}
+
+		if pg.MaxID == "" || pg.MinID == "" {
+			break LOOP
+		}
+
+		pg.SinceID = ""
+		pg.MinID = ""
+		pg.Limit = paginationLimit
+
+		cmd.rest(rl, restFloorPoll)
+	}
+	log.Info().Msgf("Found %d boosts to unboost", len(toUnboost))
+
+	for _, status := range toUnboost {
+		logger := log.With().
+			Interface("id", status.ID).
+			Str("url", status.URL).
+			Time("created", status.CreatedAt).
+			Logger()
+
+		if cmd.DryRun {
+			logger.Warn().Msg("dry run: would unboost otherwise")
+			continue
+		}
+
+		logger.Info().Msg("unboosting status")
+		if _, err := c.Unreblog(ctx, status.Reblog.ID); err != nil {
+			logger.Error().Err(err).Msg("error when unboosting")
+		}
+
+		cmd.rest(rl, restFloorDelete)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+// Code originally developed by sungo (https://sungo.io)
+// Distributed under the terms of the 0BSD license https://opensource.org/licenses/0BSD
+
+import (
+	"context"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+	"github.com/rs/zerolog/log"
+)
+
+// UnfavCmd walks the authenticated user's favourites and unfavourites
+// anything older than Age, shrinking a footprint without touching other
+// people's statuses the way deletion would. The Mastodon API doesn't expose
+// when a status was favourited, so Age is measured from the status's own
+// CreatedAt, not from when it was favourited.
+type UnfavCmd struct {
+	Globals `kong:"embed"`
+	Age     time.Duration `kong:"name='age',default='${defaultUnfavAge}',help='unfavourite toots whose post is this old or older (age is measured from when the toot was posted, not when it was favourited)'"`
+}
+
+func (cmd *UnfavCmd) Run() error {
+	cmd.setupLogging()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, rl, _, _, err := cmd.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-cmd.Age)
+
+	var (
+		pg      mastodon.Pagination
+		toUnfav = make([]*mastodon.Status, 0)
+	)
+
+	pg.Limit = int64(paginationLimit)
+	log.Debug().Int("max_toots", cmd.Count).Time("cutoff", cutoff).Msg("starting unfav run")
+
+LOOP:
+	for {
+		statuses, err := c.GetFavourites(ctx, &pg)
+		if err != nil {
+			return err
+		}
+
+		log.Debug().Int("count", len(statuses)).Msg("found favourites to consider")
+
+		for _, status := range statuses {
+			// CreatedAt is the post's own age, not when we favourited it;
+			// see the UnfavCmd doc comment.
+			if !status.CreatedAt.Before(cutoff) {
+				continue
+			}
+
+			toUnfav = append(toUnfav, status)
+			if len(toUnfav) >= cmd.Count {
+				break LOOP
+			}
+		}
+
+		if pg.MaxID == "" || pg.MinID == "" {
+			break LOOP
+		}
+
+		pg.SinceID = ""
+		pg.MinID = ""
+		pg.Limit = paginationLimit
+
+		cmd.rest(rl, restFloorPoll)
+	}
+	log.Info().Msgf("Found %d favourites to unfavourite", len(toUnfav))
+
+	for _, status := range toUnfav {
+		logger := log.With().
+			Interface("id", status.ID).
+			Str("url", status.URL).
+			Time("created", status.CreatedAt).
+			Logger()
+
+		if cmd.DryRun {
+			logger.Warn().Msg("dry run: would unfavourite otherwise")
+			continue
+		}
+
+		logger.Info().Msg("unfavouriting status")
+		if _, err := c.Unfavourite(ctx, status.ID); err != nil {
+			logger.Error().Err(err).Msg("error when unfavouriting")
+		}
+
+		cmd.rest(rl, restFloorDelete)
+	}
+
+	return nil
+}